@@ -0,0 +1,44 @@
+package main
+
+import "strings"
+
+func init() {
+	RegisterCommand("help", handleHelpCommand)
+	RegisterCommand("stop", handleStopCommand)
+	RegisterCommand("start", handleStartCommand)
+	RegisterCommand("subscribe", handleSubscribeCommand)
+}
+
+// handleHelpCommand lists the available commands.
+func handleHelpCommand(sess *Session, args []string) (string, error) {
+	return "Available commands: HELP, STOP, START, SUBSCRIBE <topic>", nil
+}
+
+// handleStopCommand opts the sender out of further messages.
+func handleStopCommand(sess *Session, args []string) (string, error) {
+	sess.Set("optedOut", "true")
+	return "You have been unsubscribed and will not receive further messages. Reply START to resubscribe.", nil
+}
+
+// handleStartCommand opts the sender back in after a STOP.
+func handleStartCommand(sess *Session, args []string) (string, error) {
+	sess.Delete("optedOut")
+	return "You have been resubscribed. Reply STOP to opt out at any time.", nil
+}
+
+// handleSubscribeCommand records the requested topic on the sender's session.
+func handleSubscribeCommand(sess *Session, args []string) (string, error) {
+	if len(args) == 0 {
+		return "Usage: SUBSCRIBE <topic>", nil
+	}
+	topic := strings.ToLower(args[0])
+	sess.Set("topic", topic)
+	return "Subscribed to " + topic + ".", nil
+}
+
+// isOptedOut reports whether sess's sender has opted out via STOP and not
+// yet opted back in with START.
+func isOptedOut(sess *Session) bool {
+	v, _ := sess.Get("optedOut")
+	return v == "true"
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+	"golang.org/x/crypto/openpgp/packet"
+	_ "golang.org/x/crypto/ripemd160" // registers RIPEMD160 so openpgp.Encrypt can satisfy preferred-hash negotiation
+)
+
+// messageKeyEntity is the process-wide PGP keypair used to encrypt and
+// decrypt stored message bodies. It is loaded (or generated) once at startup.
+var messageKeyEntity *openpgp.Entity
+
+// loadOrGenerateKeyring loads the PGP keypair at privPath/pubPath, generating
+// and persisting a new RSA 4096 keypair if either file is missing.
+func loadOrGenerateKeyring(privPath, pubPath string) (*openpgp.Entity, error) {
+	if _, err := os.Stat(privPath); err == nil {
+		if _, err := os.Stat(pubPath); err == nil {
+			return readKeyPair(privPath, pubPath)
+		}
+	}
+
+	entity, err := openpgp.NewEntity("sms_receiver", "message encryption key", "", &packet.Config{
+		RSABits: 4096,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate PGP keypair: %w", err)
+	}
+
+	if err := writeArmoredKey(privPath, openpgp.PrivateKeyType, func(w io.Writer) error {
+		return entity.SerializePrivate(w, nil)
+	}); err != nil {
+		return nil, err
+	}
+	if err := writeArmoredKey(pubPath, openpgp.PublicKeyType, func(w io.Writer) error {
+		return entity.Serialize(w)
+	}); err != nil {
+		return nil, err
+	}
+
+	return entity, nil
+}
+
+// writeArmoredKey writes an ASCII-armored PGP key of the given blockType,
+// produced by serialize, to path with 0600 permissions.
+func writeArmoredKey(path, blockType string, serialize func(io.Writer) error) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	defer f.Close()
+
+	armorWriter, err := armor.Encode(f, blockType, nil)
+	if err != nil {
+		return err
+	}
+	if err := serialize(armorWriter); err != nil {
+		return err
+	}
+	return armorWriter.Close()
+}
+
+// readKeyPair reads an armored private and public key from disk into a single Entity.
+func readKeyPair(privPath, pubPath string) (*openpgp.Entity, error) {
+	privFile, err := os.Open(privPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", privPath, err)
+	}
+	defer privFile.Close()
+
+	block, err := armor.Decode(privFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", privPath, err)
+	}
+	entity, err := openpgp.ReadEntity(packet.NewReader(block.Body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read private key from %s: %w", privPath, err)
+	}
+
+	return entity, nil
+}
+
+// keyFingerprint returns the hex-encoded fingerprint of entity's primary key,
+// used to tag encrypted rows so old keys can be rotated out.
+func keyFingerprint(entity *openpgp.Entity) string {
+	return hex.EncodeToString(entity.PrimaryKey.Fingerprint[:])
+}
+
+// encryptBody encrypts plaintext to entity's public key, returning the raw
+// (non-armored) PGP ciphertext suitable for storage in body_encrypted.
+func encryptBody(entity *openpgp.Entity, plaintext string) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := openpgp.Encrypt(&buf, []*openpgp.Entity{entity}, nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.WriteString(w, plaintext); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decryptBody decrypts ciphertext previously produced by encryptBody using entity's private key.
+func decryptBody(entity *openpgp.Entity, ciphertext []byte) (string, error) {
+	keyring := openpgp.EntityList{entity}
+	md, err := openpgp.ReadMessage(bytes.NewReader(ciphertext), keyring, nil, nil)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := ioutil.ReadAll(md.UnverifiedBody)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
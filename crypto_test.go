@@ -0,0 +1,82 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/packet"
+)
+
+// testEntity generates a throwaway (small, fast) PGP keypair for tests.
+func testEntity(t *testing.T) *openpgp.Entity {
+	t.Helper()
+	entity, err := openpgp.NewEntity("test", "crypto_test.go", "", &packet.Config{RSABits: 1024})
+	if err != nil {
+		t.Fatalf("failed to generate test keypair: %v", err)
+	}
+	return entity
+}
+
+func TestEncryptDecryptBodyRoundTrip(t *testing.T) {
+	entity := testEntity(t)
+
+	ciphertext, err := encryptBody(entity, "hello, world")
+	if err != nil {
+		t.Fatalf("encryptBody() error = %v", err)
+	}
+
+	plaintext, err := decryptBody(entity, ciphertext)
+	if err != nil {
+		t.Fatalf("decryptBody() error = %v", err)
+	}
+	if plaintext != "hello, world" {
+		t.Errorf("decryptBody() = %q, want %q", plaintext, "hello, world")
+	}
+}
+
+func TestDecryptBodyWrongKeyFails(t *testing.T) {
+	entity := testEntity(t)
+	otherEntity := testEntity(t)
+
+	ciphertext, err := encryptBody(entity, "secret")
+	if err != nil {
+		t.Fatalf("encryptBody() error = %v", err)
+	}
+
+	if _, err := decryptBody(otherEntity, ciphertext); err == nil {
+		t.Error("decryptBody() with the wrong key should fail")
+	}
+}
+
+func TestLoadOrGenerateKeyringPersistsAcrossCalls(t *testing.T) {
+	dir := t.TempDir()
+	privPath := filepath.Join(dir, "priv.asc")
+	pubPath := filepath.Join(dir, "pub.asc")
+
+	first, err := loadOrGenerateKeyring(privPath, pubPath)
+	if err != nil {
+		t.Fatalf("loadOrGenerateKeyring() first call error = %v", err)
+	}
+
+	second, err := loadOrGenerateKeyring(privPath, pubPath)
+	if err != nil {
+		t.Fatalf("loadOrGenerateKeyring() second call error = %v", err)
+	}
+
+	if keyFingerprint(first) != keyFingerprint(second) {
+		t.Error("loadOrGenerateKeyring() should load the same keypair back rather than generating a new one")
+	}
+
+	ciphertext, err := encryptBody(first, "round trip across reload")
+	if err != nil {
+		t.Fatalf("encryptBody() error = %v", err)
+	}
+	plaintext, err := decryptBody(second, ciphertext)
+	if err != nil {
+		t.Fatalf("decryptBody() error = %v", err)
+	}
+	if plaintext != "round trip across reload" {
+		t.Errorf("decryptBody() = %q, want %q", plaintext, "round trip across reload")
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"strings"
+	"sync"
+)
+
+// Handler processes a command's arguments against the sender's session and
+// returns the reply text to send back in place of the default TwiML message.
+type Handler func(sess *Session, args []string) (string, error)
+
+// CommandRegistry maps command names (the first whitespace-separated token
+// of an inbound message body) to their Handler.
+type CommandRegistry struct {
+	mu       sync.RWMutex
+	handlers map[string]Handler
+}
+
+// newCommandRegistry returns an empty CommandRegistry.
+func newCommandRegistry() *CommandRegistry {
+	return &CommandRegistry{handlers: make(map[string]Handler)}
+}
+
+// Register adds or replaces the Handler for name.
+func (c *CommandRegistry) Register(name string, h Handler) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.handlers[strings.ToLower(name)] = h
+}
+
+// Lookup returns the Handler registered for name, if any.
+func (c *CommandRegistry) Lookup(name string) (Handler, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	h, ok := c.handlers[strings.ToLower(name)]
+	return h, ok
+}
+
+// defaultRegistry is the process-wide CommandRegistry used by handleSMS.
+var defaultRegistry = newCommandRegistry()
+
+// sessionStore is the process-wide SessionStore used by handleSMS. It
+// defaults to an in-memory store and is overridden in main() when
+// SESSION_STORE=mysql is configured.
+var sessionStore SessionStore = newMemorySessionStore()
+
+// RegisterCommand registers h under name on the default CommandRegistry, so
+// packages can add inbound SMS commands without reaching into dispatcher internals.
+func RegisterCommand(name string, h Handler) {
+	defaultRegistry.Register(name, h)
+}
+
+// commandName returns the first whitespace-separated token of body, the
+// same token dispatch uses to look up a Handler.
+func commandName(body string) string {
+	fields := strings.Fields(body)
+	if len(fields) == 0 {
+		return ""
+	}
+	return fields[0]
+}
+
+// dispatch parses body as "command arg1 arg2 ...", looks up a registered
+// Handler for the sender's session, and runs it. matched is false when body
+// did not name a registered command, in which case reply is empty.
+func dispatch(sess *Session, body string) (reply string, matched bool, err error) {
+	fields := strings.Fields(body)
+	if len(fields) == 0 {
+		return "", false, nil
+	}
+
+	handler, ok := defaultRegistry.Lookup(fields[0])
+	if !ok {
+		return "", false, nil
+	}
+
+	reply, err = handler(sess, fields[1:])
+	return reply, true, err
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+// TestIssueAndParseJWT verifies that a token issued for a phone number can be
+// parsed back to the same subject, and that tokens are rejected once
+// JWT_SECRET changes.
+func TestIssueAndParseJWT(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+
+	token, err := issueJWT("+18005551212")
+	if err != nil {
+		t.Fatalf("issueJWT() error = %v", err)
+	}
+
+	phone, err := parseJWT(token)
+	if err != nil {
+		t.Fatalf("parseJWT() error = %v", err)
+	}
+	if phone != "+18005551212" {
+		t.Errorf("parseJWT() subject = %q, want %q", phone, "+18005551212")
+	}
+
+	os.Setenv("JWT_SECRET", "different-secret")
+	if _, err := parseJWT(token); err == nil {
+		t.Error("parseJWT() should fail once JWT_SECRET changes")
+	}
+}
+
+// TestParseJWTRejectsGarbage ensures malformed tokens are rejected rather than panicking.
+func TestParseJWTRejectsGarbage(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+
+	if _, err := parseJWT("not-a-jwt"); err == nil {
+		t.Error("parseJWT() should reject a malformed token")
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"log/syslog"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// requestIDKey is the context key under which the per-request correlation ID is stored.
+type requestIDKey struct{}
+
+// logger is the process-wide structured logger. It defaults to a plain
+// stdout JSON logger so logging works before main() calls initLogger with
+// the configured sink, and in tests that never call it at all.
+var logger = slog.New(slog.NewJSONHandler(os.Stdout, nil))
+
+// initLogger builds the structured JSON logger for the sink named by LOG_SINK
+// (stdout, file, or syslog), defaulting to stdout.
+func initLogger() *slog.Logger {
+	var handler slog.Handler
+
+	switch strings.ToLower(os.Getenv("LOG_SINK")) {
+	case "file":
+		path := os.Getenv("LOG_FILE")
+		if path == "" {
+			path = "sms_receiver.log"
+		}
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			handler = slog.NewJSONHandler(os.Stdout, nil)
+			break
+		}
+		handler = slog.NewJSONHandler(f, nil)
+	case "syslog":
+		w, err := syslog.New(syslog.LOG_INFO, "sms_receiver")
+		if err != nil {
+			handler = slog.NewJSONHandler(os.Stdout, nil)
+			break
+		}
+		handler = slog.NewJSONHandler(w, nil)
+	default:
+		handler = slog.NewJSONHandler(os.Stdout, nil)
+	}
+
+	return slog.New(handler)
+}
+
+// pseudonymizePII hashes value with SHA-256 when PII_HASH=true, otherwise returns it unchanged.
+func pseudonymizePII(value string) string {
+	if !strings.EqualFold(os.Getenv("PII_HASH"), "true") {
+		return value
+	}
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:])
+}
+
+// requestIDFromContext returns the correlation ID stored in ctx, if any.
+func requestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// requestIDMiddleware assigns each request a correlation ID, honoring an
+// incoming X-Request-ID header, threads it through the request context, and
+// echoes it back on the response.
+func requestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get("X-Request-ID")
+		if requestID == "" {
+			requestID = uuid.NewString()
+		}
+
+		w.Header().Set("X-Request-ID", requestID)
+		ctx := context.WithValue(r.Context(), requestIDKey{}, requestID)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// logError logs an error-level structured event for ctx's request.
+func logError(ctx context.Context, event, message string) {
+	logger.Error(message, "event", event, "request_id", requestIDFromContext(ctx))
+}
+
+// logInfo logs an info-level structured event for ctx's request, with
+// optional message_sid/from_number/remote_ip/latency_ms attributes.
+func logInfo(ctx context.Context, event, message string, attrs ...any) {
+	args := append([]any{"event", event, "request_id", requestIDFromContext(ctx)}, attrs...)
+	logger.Info(message, args...)
+}
+
+// instrumentedHandler wraps h to record handler latency and log its outcome
+// once the handler returns.
+func instrumentedHandler(route string, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		h(w, r)
+		latency := time.Since(start)
+
+		logInfo(r.Context(), "request_handled", "Handled request",
+			"route", route,
+			"remote_ip", r.RemoteAddr,
+			"latency_ms", latency.Milliseconds(),
+		)
+		observeHandlerLatency(route, latency)
+	}
+}
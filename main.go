@@ -1,9 +1,9 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
-	"log"
 	"net/http"
 	"net/url"
 	"os"
@@ -11,7 +11,6 @@ import (
 	"time"
 
 	_ "github.com/go-sql-driver/mysql"
-	"github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
 	"github.com/joho/godotenv"
 )
@@ -27,16 +26,13 @@ type SMSMessage struct {
 	ReceivedAt  time.Time
 }
 
-// logError logs errors in a structured format
-func logError(errorType, message string) {
-	log.Printf("[%s] %s", errorType, message)
-}
-
 // handleSMS handles incoming SMS webhooks from Twilio
 func handleSMS(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
 	// Parse form data
 	if err := r.ParseForm(); err != nil {
-		logError("WEBHOOK_INVALID_FORM", fmt.Sprintf("Failed to parse form data: %v", err))
+		logError(ctx, "WEBHOOK_INVALID_FORM", fmt.Sprintf("Failed to parse form data: %v", err))
 		http.Error(w, "Invalid form data", http.StatusBadRequest)
 		return
 	}
@@ -64,7 +60,7 @@ func handleSMS(w http.ResponseWriter, r *http.Request) {
 			// Decode URL-encoded body
 			parsed, err := url.ParseQuery(bodyParam)
 			if err != nil {
-				logError("WEBHOOK_INVALID_BODY", fmt.Sprintf("Failed to parse body parameter: %v", err))
+				logError(ctx, "WEBHOOK_INVALID_BODY", fmt.Sprintf("Failed to parse body parameter: %v", err))
 				http.Error(w, "Invalid body parameter", http.StatusBadRequest)
 				return
 			}
@@ -91,7 +87,7 @@ func handleSMS(w http.ResponseWriter, r *http.Request) {
 
 	// Validate required fields
 	if messageSID == "" || fromNumber == "" || body == "" {
-		logError("WEBHOOK_NO_INPUT", fmt.Sprintf("Missing required fields: MessageSid=%s, From=%s, Body=%s", messageSID, fromNumber, body))
+		logError(ctx, "WEBHOOK_NO_INPUT", fmt.Sprintf("Missing required fields: MessageSid=%s, From=%s, Body=%s", messageSID, pseudonymizePII(fromNumber), body))
 		http.Error(w, "Missing required fields", http.StatusBadRequest)
 		return
 	}
@@ -107,40 +103,85 @@ func handleSMS(w http.ResponseWriter, r *http.Request) {
 	// Save to database
 	err := saveSMS(sms)
 	if err != nil {
-		logError("DB_SAVE_ERROR", fmt.Sprintf("Failed to save SMS to database: %v", err))
+		logError(ctx, "DB_SAVE_ERROR", fmt.Sprintf("Failed to save SMS to database: %v", err))
+		smsSaveErrorsTotal.Inc()
 		http.Error(w, "Failed to save message", http.StatusInternalServerError)
 		return
 	}
+	smsReceivedTotal.Inc()
+
+	logInfo(ctx, "sms_saved", "Saved SMS",
+		"message_sid", messageSID,
+		"from_number", pseudonymizePII(fromNumber),
+	)
+
+	// If this reply matches a pending OTP challenge for this sender, mark it verified.
+	if err := recordSMSCodeIfMatching(fromNumber, body); err != nil {
+		logError(ctx, "OTP_CHALLENGE_UPDATE_ERROR", fmt.Sprintf("Failed to check SMS body against OTP challenges: %v", err))
+	}
 
-	log.Printf("Saved SMS from %s: %s", fromNumber, body)
+	// Dispatch the message body as a command, if it names one, and use its
+	// reply in place of the default acknowledgement. Senders who have opted
+	// out via STOP receive no reply at all, other than to START again.
+	replyText := "Message received! Thank you."
+	sess, err := sessionStore.Get(fromNumber)
+	if err != nil {
+		logError(ctx, "SESSION_LOAD_ERROR", fmt.Sprintf("Failed to load session for %s: %v", pseudonymizePII(fromNumber), err))
+	} else {
+		if isOptedOut(sess) && !strings.EqualFold(commandName(body), "start") {
+			replyText = ""
+		} else if reply, matched, err := dispatch(sess, body); err != nil {
+			logError(ctx, "DISPATCH_ERROR", fmt.Sprintf("Command handler for %s failed: %v", pseudonymizePII(fromNumber), err))
+		} else if matched {
+			replyText = reply
+		}
+		if err := sessionStore.Save(sess); err != nil {
+			logError(ctx, "SESSION_SAVE_ERROR", fmt.Sprintf("Failed to save session for %s: %v", pseudonymizePII(fromNumber), err))
+		}
+	}
 
 	// Respond with TwiML to acknowledge webhook
-	twimlResponse := `<?xml version="1.0" encoding="UTF-8"?>
+	var twimlResponse string
+	if replyText == "" {
+		twimlResponse = `<?xml version="1.0" encoding="UTF-8"?>
+<Response></Response>`
+	} else {
+		twimlResponse = `<?xml version="1.0" encoding="UTF-8"?>
 <Response>
-    <Message>Message received! Thank you.</Message>
+    <Message>` + escapeTwiML(replyText) + `</Message>
 </Response>`
+	}
 
 	w.Header().Set("Content-Type", "application/xml")
 	w.WriteHeader(http.StatusOK)
 	_, err = w.Write([]byte(twimlResponse))
 	if err != nil {
-		logError("WEBHOOK_RESPONSE_ERROR", fmt.Sprintf("Error writing TwiML response: %v", err))
+		logError(ctx, "WEBHOOK_RESPONSE_ERROR", fmt.Sprintf("Error writing TwiML response: %v", err))
 	}
 }
 
-// saveSMS inserts an SMS message into the database
+// saveSMS encrypts the message body to the active PGP key and inserts the
+// resulting ciphertext into the database.
 func saveSMS(sms SMSMessage) error {
+	ciphertext, err := encryptBody(messageKeyEntity, sms.Body)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt message body: %w", err)
+	}
+
 	query := `
-		INSERT INTO sms_messages (message_sid, from_number, body, received_at)
-		VALUES (?, ?, ?, ?)`
-	_, err := db.Exec(query, sms.MessageSID, sms.FromNumber, sms.Body, sms.ReceivedAt)
+		INSERT INTO sms_messages (message_sid, from_number, body_encrypted, key_fingerprint, received_at)
+		VALUES (?, ?, ?, ?, ?)`
+	_, err = db.Exec(query, sms.MessageSID, sms.FromNumber, ciphertext, keyFingerprint(messageKeyEntity), sms.ReceivedAt)
 	return err
 }
 
 func main() {
+	logger = initLogger()
+	startupCtx := context.Background()
+
 	// Load environment variables
 	if err := godotenv.Load(); err != nil {
-		logError("STARTUP_ERROR", fmt.Sprintf("Error loading .env file: %v", err))
+		logError(startupCtx, "STARTUP_ERROR", fmt.Sprintf("Error loading .env file: %v", err))
 		os.Exit(1)
 	}
 
@@ -156,7 +197,7 @@ func main() {
 
 	// Validate environment variables
 	if dbUser == "" || dbPass == "" || dbHost == "" || dbPort == "" || dbName == "" || listenPort == "" || certFile == "" || keyFile == "" {
-		logError("CONFIG_ERROR", "Missing required environment variables")
+		logError(startupCtx, "CONFIG_ERROR", "Missing required environment variables")
 		os.Exit(1)
 	}
 
@@ -165,7 +206,7 @@ func main() {
 	var err error
 	db, err = sql.Open("mysql", dsn)
 	if err != nil {
-		logError("DB_CONNECTION_ERROR", fmt.Sprintf("Failed to connect to DB: %v", err))
+		logError(startupCtx, "DB_CONNECTION_ERROR", fmt.Sprintf("Failed to connect to DB: %v", err))
 		os.Exit(1)
 	}
 	defer db.Close()
@@ -173,31 +214,97 @@ func main() {
 	// Test database connection
 	err = db.Ping()
 	if err != nil {
-		logError("DB_PING_ERROR", fmt.Sprintf("Database ping failed: %v", err))
+		logError(startupCtx, "DB_PING_ERROR", fmt.Sprintf("Database ping failed: %v", err))
 		os.Exit(1)
 	}
 
+	// Load (or generate) the PGP keypair used to encrypt stored message bodies
+	privKeyPath := os.Getenv("PGP_PRIVATE_KEY_FILE")
+	pubKeyPath := os.Getenv("PGP_PUBLIC_KEY_FILE")
+	if privKeyPath == "" || pubKeyPath == "" {
+		logError(startupCtx, "CONFIG_ERROR", "Missing required environment variables: PGP_PRIVATE_KEY_FILE, PGP_PUBLIC_KEY_FILE")
+		os.Exit(1)
+	}
+	messageKeyEntity, err = loadOrGenerateKeyring(privKeyPath, pubKeyPath)
+	if err != nil {
+		logError(startupCtx, "PGP_KEY_ERROR", fmt.Sprintf("Failed to load or generate PGP keypair: %v", err))
+		os.Exit(1)
+	}
+
+	// `rotate-keys` re-encrypts historical rows from every retired keypair
+	// archived in PGP_OLD_KEYS_DIR to the currently loaded (new) keypair,
+	// then exits. Archive the outgoing PGP_PRIVATE_KEY_FILE/PGP_PUBLIC_KEY_FILE
+	// into that directory (named by its fingerprint) before generating the
+	// new keypair, so rows from every past generation stay decryptable.
+	if len(os.Args) > 1 && os.Args[1] == "rotate-keys" {
+		oldKeysDir := os.Getenv("PGP_OLD_KEYS_DIR")
+		if oldKeysDir == "" {
+			logError(startupCtx, "CONFIG_ERROR", "rotate-keys requires PGP_OLD_KEYS_DIR to point at the directory of retired keypairs being rotated out")
+			os.Exit(1)
+		}
+		oldKeys, err := loadRetiredKeyring(oldKeysDir)
+		if err != nil {
+			logError(startupCtx, "PGP_KEY_ERROR", fmt.Sprintf("Failed to load retired PGP keypairs: %v", err))
+			os.Exit(1)
+		}
+		if err := runRotateKeys(oldKeys, messageKeyEntity); err != nil {
+			logError(startupCtx, "ROTATE_KEYS_ERROR", fmt.Sprintf("Key rotation failed: %v", err))
+			os.Exit(1)
+		}
+		return
+	}
+
+	// `purge-plaintext` encrypts any rows still holding a plaintext body from
+	// before 0001_add_pgp_encryption.sql and clears it, then exits.
+	if len(os.Args) > 1 && os.Args[1] == "purge-plaintext" {
+		if err := runPurgePlaintext(messageKeyEntity); err != nil {
+			logError(startupCtx, "PURGE_PLAINTEXT_ERROR", fmt.Sprintf("Plaintext purge failed: %v", err))
+			os.Exit(1)
+		}
+		return
+	}
+
 	// Verify certificate and key files exist
 	if _, err := os.Stat(certFile); os.IsNotExist(err) {
-		logError("CONFIG_ERROR", fmt.Sprintf("Certificate file not found: %s", certFile))
+		logError(startupCtx, "CONFIG_ERROR", fmt.Sprintf("Certificate file not found: %s", certFile))
 		os.Exit(1)
 	}
 	if _, err := os.Stat(keyFile); os.IsNotExist(err) {
-		logError("CONFIG_ERROR", fmt.Sprintf("Key file not found: %s", keyFile))
+		logError(startupCtx, "CONFIG_ERROR", fmt.Sprintf("Key file not found: %s", keyFile))
 		os.Exit(1)
 	}
 
+	// Session store for the command dispatcher
+	if strings.EqualFold(os.Getenv("SESSION_STORE"), "mysql") {
+		sessionStore = newMySQLSessionStore(db)
+	}
+
+	// Twilio request signature validation
+	twilioAuthToken := os.Getenv("TWILIO_AUTH_TOKEN")
+	signatureMode := parseSignatureValidationMode(os.Getenv("SIGNATURE_VALIDATION"))
+
+	// Outbound sending and webhook self-registration
+	if twilioSID := os.Getenv("TWILIO_SID"); twilioSID != "" {
+		twilioOutbound = newTwilioClient(twilioSID, os.Getenv("TWILIO_TOKEN"))
+		registerInboundWebhook(twilioOutbound)
+	}
+
 	// Initialize router
 	r := mux.NewRouter()
-	r.HandleFunc("/sms", handleSMS).Methods("POST")
+	r.HandleFunc("/sms", instrumentedHandler("/sms", validateTwilioSignature(twilioAuthToken, signatureMode, handleSMS))).Methods("POST")
+	r.HandleFunc("/init", instrumentedHandler("/init", handleOTPInit)).Methods("POST")
+	r.HandleFunc("/verify", instrumentedHandler("/verify", handleOTPVerify)).Methods("POST")
+	r.HandleFunc("/send", instrumentedHandler("/send", handleSend)).Methods("POST")
+	r.HandleFunc("/messages/{sid}", instrumentedHandler("/messages/{sid}", handleGetMessage)).Methods("GET")
+	r.HandleFunc("/healthz", handleHealthz).Methods("GET")
+	r.Handle("/metrics", metricsHandler()).Methods("GET")
 
-	// Enable CORS and logging
-	loggedRouter := handlers.LoggingHandler(os.Stdout, r)
+	instrumentedRouter := requestIDMiddleware(r)
 
 	// Start HTTPS server
-	log.Printf("Starting HTTPS server on port %s", listenPort)
-	if err := http.ListenAndServeTLS(":"+listenPort, certFile, keyFile, loggedRouter); err != nil {
-		logError("SERVER_ERROR", fmt.Sprintf("Failed to start HTTPS server: %v", err))
+	logInfo(startupCtx, "server_starting", fmt.Sprintf("Starting HTTPS server on port %s", listenPort))
+	if err := http.ListenAndServeTLS(":"+listenPort, certFile, keyFile, instrumentedRouter); err != nil {
+		logError(startupCtx, "SERVER_ERROR", fmt.Sprintf("Failed to start HTTPS server: %v", err))
 		os.Exit(1)
 	}
-}
\ No newline at end of file
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// handleGetMessage handles GET /messages/{sid}, decrypting the stored body
+// after authenticating the caller with a bearer token. The token's subject
+// must match the message's sender.
+func handleGetMessage(w http.ResponseWriter, r *http.Request) {
+	authHeader := r.Header.Get("Authorization")
+	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+	if tokenString == "" || tokenString == authHeader {
+		http.Error(w, "Missing bearer token", http.StatusUnauthorized)
+		return
+	}
+
+	phone, err := parseJWT(tokenString)
+	if err != nil {
+		http.Error(w, "Invalid bearer token", http.StatusUnauthorized)
+		return
+	}
+
+	sid := mux.Vars(r)["sid"]
+
+	var fromNumber string
+	var ciphertext []byte
+	var fingerprint string
+	query := `SELECT from_number, body_encrypted, key_fingerprint FROM sms_messages WHERE message_sid = ?`
+	err = db.QueryRow(query, sid).Scan(&fromNumber, &ciphertext, &fingerprint)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Message not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		logError(r.Context(), "MESSAGE_FETCH_ERROR", fmt.Sprintf("Failed to fetch message %s: %v", sid, err))
+		http.Error(w, "Failed to fetch message", http.StatusInternalServerError)
+		return
+	}
+
+	if fromNumber != phone {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	entity := messageKeyEntity
+	if fingerprint != keyFingerprint(messageKeyEntity) {
+		logError(r.Context(), "MESSAGE_KEY_MISMATCH", fmt.Sprintf("Message %s was encrypted with key %s, active key is %s", sid, fingerprint, keyFingerprint(messageKeyEntity)))
+		http.Error(w, "Message encrypted with a rotated key", http.StatusGone)
+		return
+	}
+
+	body, err := decryptBody(entity, ciphertext)
+	if err != nil {
+		logError(r.Context(), "MESSAGE_DECRYPT_ERROR", fmt.Sprintf("Failed to decrypt message %s: %v", sid, err))
+		http.Error(w, "Failed to decrypt message", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"message_sid": sid,
+		"from_number": fromNumber,
+		"body":        body,
+	})
+}
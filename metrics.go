@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// smsReceivedTotal counts successfully persisted inbound SMS messages.
+var smsReceivedTotal = prometheus.NewCounter(prometheus.CounterOpts{
+	Name: "sms_received_total",
+	Help: "Total number of inbound SMS messages successfully saved.",
+})
+
+// smsSaveErrorsTotal counts failures to persist an inbound SMS message.
+var smsSaveErrorsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+	Name: "sms_save_errors_total",
+	Help: "Total number of inbound SMS messages that failed to save.",
+})
+
+// handlerLatencySeconds observes request handling latency by route.
+var handlerLatencySeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Name: "handler_latency_seconds",
+	Help: "Request handling latency in seconds, by route.",
+}, []string{"route"})
+
+func init() {
+	prometheus.MustRegister(smsReceivedTotal, smsSaveErrorsTotal, handlerLatencySeconds)
+}
+
+// observeHandlerLatency records latency against route's histogram.
+func observeHandlerLatency(route string, latency time.Duration) {
+	handlerLatencySeconds.WithLabelValues(route).Observe(latency.Seconds())
+}
+
+// metricsHandler exposes all registered Prometheus metrics for /metrics.
+func metricsHandler() http.Handler {
+	return promhttp.Handler()
+}
+
+// handleHealthz handles GET /healthz, reporting OK once the database is reachable.
+func handleHealthz(w http.ResponseWriter, r *http.Request) {
+	if err := db.Ping(); err != nil {
+		logError(r.Context(), "HEALTHZ_DB_ERROR", fmt.Sprintf("Database ping failed: %v", err))
+		http.Error(w, "unhealthy", http.StatusServiceUnavailable)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("ok"))
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// defaultOTPTTL is used when OTP_TTL is unset or invalid.
+const defaultOTPTTL = 120 * time.Second
+
+// otpCodeLength is the number of digits in a generated verification code.
+const otpCodeLength = 6
+
+// otpMaxAttempts bounds how many guesses recordSMSCodeIfMatching will accept
+// against a single challenge before it is locked out, to block brute-forcing
+// a 6-digit code over unauthenticated /sms requests.
+const otpMaxAttempts = 5
+
+// e164Pattern is a conservative E.164 phone number validator.
+var e164Pattern = regexp.MustCompile(`^\+[1-9]\d{1,14}$`)
+
+// otpTTL parses OTP_TTL (seconds) from the environment.
+func otpTTL() time.Duration {
+	raw := os.Getenv("OTP_TTL")
+	if raw == "" {
+		return defaultOTPTTL
+	}
+	seconds, err := strconv.Atoi(raw)
+	if err != nil || seconds <= 0 {
+		return defaultOTPTTL
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// normalizeE164 validates and returns phone in E.164 form.
+func normalizeE164(phone string) (string, error) {
+	if !e164Pattern.MatchString(phone) {
+		return "", fmt.Errorf("phone number is not in E.164 format")
+	}
+	return phone, nil
+}
+
+// generateNonce returns a random hex-encoded nonce identifying a challenge.
+func generateNonce() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// generateOTPCode returns a random numeric code of otpCodeLength digits.
+func generateOTPCode() (string, error) {
+	max := new(big.Int).Exp(big.NewInt(10), big.NewInt(otpCodeLength), nil)
+	n, err := rand.Int(rand.Reader, max)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%0*d", otpCodeLength, n), nil
+}
+
+// hashCode returns the hex-encoded SHA-256 digest of code.
+func hashCode(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+// createOTPChallenge generates a code and nonce for phone and stores the
+// challenge in otp_challenges. It returns the nonce to hand back to the
+// caller and the code, which the caller is responsible for delivering to
+// phone (e.g. over SMS) — it is never returned over the API.
+func createOTPChallenge(phone string) (nonce, code string, err error) {
+	code, err = generateOTPCode()
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate OTP code: %w", err)
+	}
+	nonce, err = generateNonce()
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	query := `
+		INSERT INTO otp_challenges (phone_number, code_hash, nonce, expires_at, verified)
+		VALUES (?, ?, ?, ?, FALSE)`
+	_, err = db.Exec(query, phone, hashCode(code), nonce, time.Now().Add(otpTTL()))
+	if err != nil {
+		return "", "", err
+	}
+	return nonce, code, nil
+}
+
+// recordSMSCodeIfMatching marks the most recent unverified, unexpired,
+// not-yet-locked-out challenge for fromNumber as verified if body matches its
+// code. A non-matching body counts as a failed attempt against that same
+// challenge, up to otpMaxAttempts, to bound brute-forcing the code.
+func recordSMSCodeIfMatching(fromNumber, body string) error {
+	result, err := db.Exec(`
+		UPDATE otp_challenges
+		SET verified = TRUE
+		WHERE phone_number = ? AND code_hash = ? AND verified = FALSE AND expires_at > ? AND attempts < ?
+		ORDER BY expires_at DESC
+		LIMIT 1`, fromNumber, hashCode(body), time.Now(), otpMaxAttempts)
+	if err != nil {
+		return err
+	}
+	matched, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if matched > 0 {
+		return nil
+	}
+
+	_, err = db.Exec(`
+		UPDATE otp_challenges
+		SET attempts = attempts + 1
+		WHERE phone_number = ? AND verified = FALSE AND expires_at > ? AND attempts < ?
+		ORDER BY expires_at DESC
+		LIMIT 1`, fromNumber, time.Now(), otpMaxAttempts)
+	return err
+}
+
+// challengeVerified reports whether the challenge identified by phone and
+// nonce exists, has been verified, and has not expired.
+func challengeVerified(phone, nonce string) (bool, error) {
+	var verified bool
+	query := `
+		SELECT verified FROM otp_challenges
+		WHERE phone_number = ? AND nonce = ? AND expires_at > ?
+		LIMIT 1`
+	err := db.QueryRow(query, phone, nonce, time.Now()).Scan(&verified)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return verified, nil
+}
+
+// handleOTPInit handles POST /init, issuing a new OTP challenge for a phone number.
+func handleOTPInit(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		PhoneNumber string `json:"phone_number"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logError(r.Context(), "OTP_INIT_INVALID_BODY", fmt.Sprintf("Failed to decode request body: %v", err))
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	phone, err := normalizeE164(req.PhoneNumber)
+	if err != nil {
+		logError(r.Context(), "OTP_INIT_INVALID_PHONE", err.Error())
+		http.Error(w, "Invalid phone number", http.StatusBadRequest)
+		return
+	}
+
+	if twilioOutbound == nil {
+		logError(r.Context(), "OTP_INIT_NOT_CONFIGURED", "Received /init request but TWILIO_SID/TWILIO_TOKEN are not configured to deliver the code")
+		http.Error(w, "OTP delivery is not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	sess, err := sessionStore.Get(phone)
+	if err != nil {
+		logError(r.Context(), "OTP_INIT_SESSION_ERROR", fmt.Sprintf("Failed to load session for %s: %v", pseudonymizePII(phone), err))
+		http.Error(w, "Failed to check opt-out status", http.StatusInternalServerError)
+		return
+	}
+	if isOptedOut(sess) {
+		http.Error(w, "Recipient has opted out", http.StatusForbidden)
+		return
+	}
+
+	nonce, code, err := createOTPChallenge(phone)
+	if err != nil {
+		logError(r.Context(), "OTP_INIT_ERROR", fmt.Sprintf("Failed to create OTP challenge: %v", err))
+		http.Error(w, "Failed to create challenge", http.StatusInternalServerError)
+		return
+	}
+
+	if err := twilioOutbound.SendMessage(phone, fmt.Sprintf("Your verification code is %s", code)); err != nil {
+		logError(r.Context(), "OTP_INIT_SEND_ERROR", fmt.Sprintf("Failed to send OTP code: %v", err))
+		http.Error(w, "Failed to send verification code", http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"nonce": nonce})
+}
+
+// handleOTPVerify handles POST /verify, exchanging a verified challenge for a JWT.
+func handleOTPVerify(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		PhoneNumber string `json:"phone_number"`
+		Nonce       string `json:"nonce"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logError(r.Context(), "OTP_VERIFY_INVALID_BODY", fmt.Sprintf("Failed to decode request body: %v", err))
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	phone, err := normalizeE164(req.PhoneNumber)
+	if err != nil {
+		logError(r.Context(), "OTP_VERIFY_INVALID_PHONE", err.Error())
+		http.Error(w, "Invalid phone number", http.StatusBadRequest)
+		return
+	}
+
+	verified, err := challengeVerified(phone, req.Nonce)
+	if err != nil {
+		logError(r.Context(), "OTP_VERIFY_ERROR", fmt.Sprintf("Failed to look up OTP challenge: %v", err))
+		http.Error(w, "Failed to verify challenge", http.StatusInternalServerError)
+		return
+	}
+	if !verified {
+		http.Error(w, "Challenge not verified", http.StatusUnauthorized)
+		return
+	}
+
+	token, err := issueJWT(phone)
+	if err != nil {
+		logError(r.Context(), "OTP_VERIFY_JWT_ERROR", fmt.Sprintf("Failed to issue JWT: %v", err))
+		http.Error(w, "Failed to issue token", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"token": token})
+}
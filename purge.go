@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"golang.org/x/crypto/openpgp"
+)
+
+// purgePlaintextBatchSize bounds how many rows are encrypted and cleared per query round trip.
+const purgePlaintextBatchSize = 500
+
+// runPurgePlaintext encrypts every sms_messages row that still carries a
+// plaintext body (left over from before 0001_add_pgp_encryption.sql) to
+// entity, then clears body to NULL, in batches.
+func runPurgePlaintext(entity *openpgp.Entity) error {
+	fingerprint := keyFingerprint(entity)
+
+	for {
+		rows, err := db.Query(`
+			SELECT message_sid, body
+			FROM sms_messages
+			WHERE body IS NOT NULL
+			LIMIT ?`, purgePlaintextBatchSize)
+		if err != nil {
+			return fmt.Errorf("failed to query rows pending purge: %w", err)
+		}
+
+		type pendingRow struct {
+			sid  string
+			body string
+		}
+		var pending []pendingRow
+		for rows.Next() {
+			var row pendingRow
+			if err := rows.Scan(&row.sid, &row.body); err != nil {
+				rows.Close()
+				return fmt.Errorf("failed to scan row pending purge: %w", err)
+			}
+			pending = append(pending, row)
+		}
+		rows.Close()
+
+		if len(pending) == 0 {
+			return nil
+		}
+
+		for _, row := range pending {
+			ciphertext, err := encryptBody(entity, row.body)
+			if err != nil {
+				return fmt.Errorf("failed to encrypt %s during purge: %w", row.sid, err)
+			}
+			_, err = db.Exec(`
+				UPDATE sms_messages SET body_encrypted = ?, key_fingerprint = ?, body = NULL WHERE message_sid = ?`,
+				ciphertext, fingerprint, row.sid)
+			if err != nil {
+				return fmt.Errorf("failed to update %s during purge: %w", row.sid, err)
+			}
+		}
+
+		logInfo(context.Background(), "purge_plaintext_batch", fmt.Sprintf("purge-plaintext: encrypted and cleared %d message(s)", len(pending)))
+	}
+}
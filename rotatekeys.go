@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/crypto/openpgp"
+)
+
+// rotateKeysBatchSize bounds how many rows are re-encrypted per query round trip.
+const rotateKeysBatchSize = 500
+
+// runRotateKeys re-encrypts every sms_messages row still carrying an old
+// key_fingerprint to newEntity, in batches, leaving already-current rows
+// untouched. oldKeys must contain every retired keypair rows might still be
+// encrypted under, keyed by keyFingerprint(entity) — rows survive multiple
+// rotations without being touched by every single one, so a row's
+// key_fingerprint can name any prior generation, not just the one most
+// recently superseded.
+func runRotateKeys(oldKeys map[string]*openpgp.Entity, newEntity *openpgp.Entity) error {
+	newFingerprint := keyFingerprint(newEntity)
+
+	for {
+		rows, err := db.Query(`
+			SELECT message_sid, body_encrypted, key_fingerprint
+			FROM sms_messages
+			WHERE key_fingerprint IS NOT NULL AND key_fingerprint != ?
+			LIMIT ?`, newFingerprint, rotateKeysBatchSize)
+		if err != nil {
+			return fmt.Errorf("failed to query rows pending rotation: %w", err)
+		}
+
+		type pendingRow struct {
+			sid         string
+			ciphertext  []byte
+			fingerprint string
+		}
+		var pending []pendingRow
+		for rows.Next() {
+			var row pendingRow
+			if err := rows.Scan(&row.sid, &row.ciphertext, &row.fingerprint); err != nil {
+				rows.Close()
+				return fmt.Errorf("failed to scan row pending rotation: %w", err)
+			}
+			pending = append(pending, row)
+		}
+		rows.Close()
+
+		if len(pending) == 0 {
+			return nil
+		}
+
+		for _, row := range pending {
+			oldEntity, ok := oldKeys[row.fingerprint]
+			if !ok {
+				return fmt.Errorf("no retired keypair loaded for fingerprint %s (message %s)", row.fingerprint, row.sid)
+			}
+
+			plaintext, err := decryptBody(oldEntity, row.ciphertext)
+			if err != nil {
+				return fmt.Errorf("failed to decrypt %s during rotation: %w", row.sid, err)
+			}
+			newCiphertext, err := encryptBody(newEntity, plaintext)
+			if err != nil {
+				return fmt.Errorf("failed to re-encrypt %s during rotation: %w", row.sid, err)
+			}
+			_, err = db.Exec(`
+				UPDATE sms_messages SET body_encrypted = ?, key_fingerprint = ? WHERE message_sid = ?`,
+				newCiphertext, newFingerprint, row.sid)
+			if err != nil {
+				return fmt.Errorf("failed to update %s during rotation: %w", row.sid, err)
+			}
+		}
+
+		logInfo(context.Background(), "rotate_keys_batch", fmt.Sprintf("rotate-keys: re-encrypted %d message(s) to key %s", len(pending), newFingerprint))
+	}
+}
+
+// loadRetiredKeyring loads every retired keypair archived in dir, keyed by
+// its own fingerprint, so runRotateKeys can decrypt rows from any past
+// generation rather than just the single most recently superseded key. Each
+// retired keypair is stored as a <fingerprint>_private.asc/<fingerprint>_public.asc
+// pair — when rotating, archive the outgoing PGP_PRIVATE_KEY_FILE/PGP_PUBLIC_KEY_FILE
+// into this directory under its fingerprint before generating the new keypair.
+func loadRetiredKeyring(dir string) (map[string]*openpgp.Entity, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read retired key directory %s: %w", dir, err)
+	}
+
+	keys := make(map[string]*openpgp.Entity)
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, "_private.asc") {
+			continue
+		}
+
+		fingerprint := strings.TrimSuffix(name, "_private.asc")
+		privPath := filepath.Join(dir, name)
+		pubPath := filepath.Join(dir, fingerprint+"_public.asc")
+
+		entity, err := readKeyPair(privPath, pubPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load retired keypair %s: %w", fingerprint, err)
+		}
+		keys[keyFingerprint(entity)] = entity
+	}
+	return keys, nil
+}
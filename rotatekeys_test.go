@@ -0,0 +1,70 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/crypto/openpgp"
+)
+
+// writeTestKeypair archives entity into dir as <fingerprint>_private.asc /
+// <fingerprint>_public.asc, the layout loadRetiredKeyring expects.
+func writeTestKeypair(t *testing.T, dir string, entity *openpgp.Entity) {
+	t.Helper()
+	fingerprint := keyFingerprint(entity)
+
+	if err := writeArmoredKey(filepath.Join(dir, fingerprint+"_private.asc"), openpgp.PrivateKeyType, func(w io.Writer) error {
+		return entity.SerializePrivate(w, nil)
+	}); err != nil {
+		t.Fatalf("failed to archive private key: %v", err)
+	}
+	if err := writeArmoredKey(filepath.Join(dir, fingerprint+"_public.asc"), openpgp.PublicKeyType, func(w io.Writer) error {
+		return entity.Serialize(w)
+	}); err != nil {
+		t.Fatalf("failed to archive public key: %v", err)
+	}
+}
+
+func TestLoadRetiredKeyringLoadsEveryGeneration(t *testing.T) {
+	dir := t.TempDir()
+
+	gen1 := testEntity(t)
+	gen2 := testEntity(t)
+	writeTestKeypair(t, dir, gen1)
+	writeTestKeypair(t, dir, gen2)
+
+	keys, err := loadRetiredKeyring(dir)
+	if err != nil {
+		t.Fatalf("loadRetiredKeyring() error = %v", err)
+	}
+
+	if len(keys) != 2 {
+		t.Fatalf("loadRetiredKeyring() loaded %d keypair(s), want 2", len(keys))
+	}
+	for _, entity := range []*openpgp.Entity{gen1, gen2} {
+		loaded, ok := keys[keyFingerprint(entity)]
+		if !ok {
+			t.Fatalf("loadRetiredKeyring() missing fingerprint %s", keyFingerprint(entity))
+		}
+		if keyFingerprint(loaded) != keyFingerprint(entity) {
+			t.Errorf("loaded keypair fingerprint = %s, want %s", keyFingerprint(loaded), keyFingerprint(entity))
+		}
+	}
+}
+
+func TestLoadRetiredKeyringIgnoresUnrelatedFiles(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "README.txt"), []byte("not a key"), 0600); err != nil {
+		t.Fatalf("failed to write unrelated file: %v", err)
+	}
+
+	keys, err := loadRetiredKeyring(dir)
+	if err != nil {
+		t.Fatalf("loadRetiredKeyring() error = %v", err)
+	}
+	if len(keys) != 0 {
+		t.Errorf("loadRetiredKeyring() loaded %d keypair(s), want 0", len(keys))
+	}
+}
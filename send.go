@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+)
+
+// twilioOutbound is the process-wide client used to send outbound messages
+// and self-register the inbound webhook. It is nil until configured in main().
+var twilioOutbound *twilioClient
+
+// handleSend handles POST /send, relaying {to, body} to Twilio. It requires
+// an API key matching SEND_API_KEY in the X-Api-Key header.
+func handleSend(w http.ResponseWriter, r *http.Request) {
+	apiKey := os.Getenv("SEND_API_KEY")
+	if apiKey == "" || subtle.ConstantTimeCompare([]byte(r.Header.Get("X-Api-Key")), []byte(apiKey)) != 1 {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if twilioOutbound == nil {
+		logError(r.Context(), "SEND_NOT_CONFIGURED", "Received /send request but TWILIO_SID/TWILIO_TOKEN are not configured")
+		http.Error(w, "Outbound sending is not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	var req struct {
+		To   string `json:"to"`
+		Body string `json:"body"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logError(r.Context(), "SEND_INVALID_BODY", fmt.Sprintf("Failed to decode request body: %v", err))
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.To == "" || req.Body == "" {
+		http.Error(w, "Missing required fields: to, body", http.StatusBadRequest)
+		return
+	}
+
+	sess, err := sessionStore.Get(req.To)
+	if err != nil {
+		logError(r.Context(), "SEND_SESSION_ERROR", fmt.Sprintf("Failed to load session for %s: %v", pseudonymizePII(req.To), err))
+		http.Error(w, "Failed to check opt-out status", http.StatusInternalServerError)
+		return
+	}
+	if isOptedOut(sess) {
+		http.Error(w, "Recipient has opted out", http.StatusForbidden)
+		return
+	}
+
+	if err := twilioOutbound.SendMessage(req.To, req.Body); err != nil {
+		logError(r.Context(), "SEND_ERROR", fmt.Sprintf("Failed to send message to %s: %v", pseudonymizePII(req.To), err))
+		http.Error(w, "Failed to send message", http.StatusBadGateway)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// registerInboundWebhook points the Twilio phone number named by
+// TWILIO_FRIENDLY_PHONE at {WEBHOOK_PUBLIC_URL}/sms, so the Twilio console
+// does not need to be configured by hand. It is a no-op unless both env
+// vars are set.
+func registerInboundWebhook(client *twilioClient) {
+	friendlyPhone := os.Getenv("TWILIO_FRIENDLY_PHONE")
+	publicURL := os.Getenv("WEBHOOK_PUBLIC_URL")
+	if friendlyPhone == "" || publicURL == "" {
+		return
+	}
+
+	ctx := context.Background()
+
+	numbers, err := client.GetIncomingPhoneNumbers(friendlyPhone)
+	if err != nil {
+		logError(ctx, "WEBHOOK_REGISTER_ERROR", fmt.Sprintf("Failed to look up phone number %q: %v", friendlyPhone, err))
+		return
+	}
+	if len(numbers) == 0 {
+		logError(ctx, "WEBHOOK_REGISTER_ERROR", fmt.Sprintf("No Twilio phone number found matching %q", friendlyPhone))
+		return
+	}
+
+	webhookURL := publicURL + "/sms"
+	if err := client.UpdateIncomingPhoneNumberSMSWebhook(numbers[0].SID, webhookURL); err != nil {
+		logError(ctx, "WEBHOOK_REGISTER_ERROR", fmt.Sprintf("Failed to update SMS webhook for %q: %v", friendlyPhone, err))
+		return
+	}
+
+	logInfo(ctx, "webhook_registered", fmt.Sprintf("Configured Twilio SMS webhook for %s to %s", friendlyPhone, webhookURL))
+}
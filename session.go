@@ -0,0 +1,171 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// defaultSessionIdleTimeout is used when SESSION_IDLE_TIMEOUT is unset or invalid.
+const defaultSessionIdleTimeout = 10 * time.Minute
+
+// Session holds per-sender conversational state threaded across messages.
+// memorySessionStore hands out the same *Session to every concurrent caller
+// for a given sender, so all access to Data goes through the locked
+// accessors below rather than touching the map directly.
+type Session struct {
+	FromNumber string
+	Data       map[string]string
+	LastSeen   time.Time
+
+	mu sync.Mutex
+}
+
+// Get returns the value stored for key and whether it was present.
+func (s *Session) Get(key string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, ok := s.Data[key]
+	return v, ok
+}
+
+// Set stores value for key.
+func (s *Session) Set(key, value string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Data[key] = value
+}
+
+// Delete removes key, if present.
+func (s *Session) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.Data, key)
+}
+
+// snapshotData returns a copy of Data suitable for JSON-encoding outside the lock.
+func (s *Session) snapshotData() map[string]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data := make(map[string]string, len(s.Data))
+	for k, v := range s.Data {
+		data[k] = v
+	}
+	return data
+}
+
+// lastSeen returns LastSeen.
+func (s *Session) lastSeen() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.LastSeen
+}
+
+// touch sets LastSeen to now.
+func (s *Session) touch(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.LastSeen = now
+}
+
+// SessionStore persists and retrieves per-sender Sessions.
+type SessionStore interface {
+	// Get returns the session for from, creating a fresh one if none exists
+	// or the existing one has gone idle past the configured timeout.
+	Get(from string) (*Session, error)
+	// Save persists sess.
+	Save(sess *Session) error
+}
+
+// sessionIdleTimeout parses SESSION_IDLE_TIMEOUT (seconds) from the environment.
+func sessionIdleTimeout() time.Duration {
+	raw := os.Getenv("SESSION_IDLE_TIMEOUT")
+	if raw == "" {
+		return defaultSessionIdleTimeout
+	}
+	seconds, err := strconv.Atoi(raw)
+	if err != nil || seconds <= 0 {
+		return defaultSessionIdleTimeout
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// memorySessionStore is an in-memory SessionStore, suitable for a single instance.
+type memorySessionStore struct {
+	mu       sync.Mutex
+	sessions map[string]*Session
+}
+
+// newMemorySessionStore returns an empty in-memory SessionStore.
+func newMemorySessionStore() *memorySessionStore {
+	return &memorySessionStore{sessions: make(map[string]*Session)}
+}
+
+func (s *memorySessionStore) Get(from string) (*Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sess, ok := s.sessions[from]
+	if !ok || time.Since(sess.lastSeen()) > sessionIdleTimeout() {
+		sess = &Session{FromNumber: from, Data: make(map[string]string)}
+		s.sessions[from] = sess
+	}
+	return sess, nil
+}
+
+func (s *memorySessionStore) Save(sess *Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sess.touch(time.Now())
+	s.sessions[sess.FromNumber] = sess
+	return nil
+}
+
+// mysqlSessionStore persists Sessions in the `sessions` table, for deployments
+// running multiple receiver instances against a shared database.
+type mysqlSessionStore struct {
+	db *sql.DB
+}
+
+// newMySQLSessionStore returns a SessionStore backed by conn.
+func newMySQLSessionStore(conn *sql.DB) *mysqlSessionStore {
+	return &mysqlSessionStore{db: conn}
+}
+
+func (s *mysqlSessionStore) Get(from string) (*Session, error) {
+	var dataJSON []byte
+	var lastSeen time.Time
+
+	query := `SELECT data, last_seen FROM sessions WHERE from_number = ?`
+	err := s.db.QueryRow(query, from).Scan(&dataJSON, &lastSeen)
+	if err == sql.ErrNoRows || (err == nil && time.Since(lastSeen) > sessionIdleTimeout()) {
+		return &Session{FromNumber: from, Data: make(map[string]string)}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	data := make(map[string]string)
+	if err := json.Unmarshal(dataJSON, &data); err != nil {
+		return nil, err
+	}
+	return &Session{FromNumber: from, Data: data, LastSeen: lastSeen}, nil
+}
+
+func (s *mysqlSessionStore) Save(sess *Session) error {
+	dataJSON, err := json.Marshal(sess.snapshotData())
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO sessions (from_number, data, last_seen)
+		VALUES (?, ?, ?)
+		ON DUPLICATE KEY UPDATE data = VALUES(data), last_seen = VALUES(last_seen)`
+	_, err = s.db.Exec(query, sess.FromNumber, dataJSON, time.Now())
+	return err
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"sync"
+	"testing"
+)
+
+// TestMemorySessionStoreConcurrentAccess exercises the exact Get-then-write
+// pattern the command handlers use (handleStopCommand, handleSubscribeCommand,
+// ...), from many goroutines against the same sender. Run with -race.
+func TestMemorySessionStoreConcurrentAccess(t *testing.T) {
+	store := newMemorySessionStore()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			sess, err := store.Get("+18005551212")
+			if err != nil {
+				t.Errorf("Get() error = %v", err)
+				return
+			}
+			sess.Set("optedOut", "true")
+			sess.Get("optedOut")
+			sess.Delete("optedOut")
+			if err := store.Save(sess); err != nil {
+				t.Errorf("Save() error = %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// signatureValidationMode controls how a mismatched/missing Twilio signature is handled.
+type signatureValidationMode string
+
+const (
+	signatureValidationStrict signatureValidationMode = "strict"
+	signatureValidationLog    signatureValidationMode = "log"
+	signatureValidationOff    signatureValidationMode = "off"
+)
+
+// parseSignatureValidationMode normalizes the SIGNATURE_VALIDATION env value,
+// falling back to "off" so existing deployments keep working until configured.
+func parseSignatureValidationMode(raw string) signatureValidationMode {
+	switch signatureValidationMode(strings.ToLower(strings.TrimSpace(raw))) {
+	case signatureValidationStrict:
+		return signatureValidationStrict
+	case signatureValidationLog:
+		return signatureValidationLog
+	default:
+		return signatureValidationOff
+	}
+}
+
+// requestURL reconstructs the full URL Twilio signed, honoring the
+// X-Forwarded-Proto/X-Forwarded-Host headers set by a reverse proxy.
+func requestURL(r *http.Request) string {
+	scheme := "https"
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto != "" {
+		scheme = proto
+	} else if r.TLS == nil {
+		scheme = "http"
+	}
+
+	host := r.Host
+	if forwardedHost := r.Header.Get("X-Forwarded-Host"); forwardedHost != "" {
+		host = forwardedHost
+	}
+
+	return scheme + "://" + host + r.URL.RequestURI()
+}
+
+// twilioSignature computes base64(HMAC-SHA1(authToken, s)) where s is the
+// full request URL followed by the sorted, concatenated POST form key/value
+// pairs, per Twilio's request validation scheme.
+func twilioSignature(authToken, fullURL string, form map[string][]string) string {
+	keys := make([]string, 0, len(form))
+	for k := range form {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var s strings.Builder
+	s.WriteString(fullURL)
+	for _, k := range keys {
+		s.WriteString(k)
+		s.WriteString(form[k][0])
+	}
+
+	mac := hmac.New(sha1.New, []byte(authToken))
+	mac.Write([]byte(s.String()))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// validateTwilioSignature wraps next with Twilio's X-Twilio-Signature check,
+// enforcing, logging, or ignoring mismatches according to mode.
+func validateTwilioSignature(authToken string, mode signatureValidationMode, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if mode == signatureValidationOff {
+			next(w, r)
+			return
+		}
+
+		if err := r.ParseForm(); err != nil {
+			logError(r.Context(), "WEBHOOK_INVALID_FORM", "Failed to parse form data for signature validation: "+err.Error())
+			http.Error(w, "Invalid form data", http.StatusBadRequest)
+			return
+		}
+
+		expected := twilioSignature(authToken, requestURL(r), r.PostForm)
+		got := r.Header.Get("X-Twilio-Signature")
+
+		if hmac.Equal([]byte(expected), []byte(got)) {
+			next(w, r)
+			return
+		}
+
+		logError(r.Context(), "WEBHOOK_SIGNATURE_MISMATCH", "X-Twilio-Signature did not match computed signature for "+requestURL(r))
+
+		if mode == signatureValidationStrict {
+			http.Error(w, "Invalid signature", http.StatusForbidden)
+			return
+		}
+
+		next(w, r)
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// twilioVectorAuthToken, twilioVectorURL, twilioVectorForm and
+// twilioVectorSignature are the request/response pair from Twilio's
+// "Validating Requests" documentation example (AuthToken 12345 against
+// https://mycompany.com/myapp.php?foo=1&bar=2), which is also the fixture
+// used by Twilio's own client-library test suites.
+var (
+	twilioVectorAuthToken = "12345"
+	twilioVectorURL       = "https://mycompany.com/myapp.php?foo=1&bar=2"
+	twilioVectorForm      = url.Values{
+		"CallSid": {"CA1234567890ABCDE123456789"},
+		"Caller":  {"+14158675310"},
+		"Digits":  {"1234"},
+		"From":    {"+14158675310"},
+		"To":      {"+18005551212"},
+	}
+	twilioVectorSignature = "wLrLz7ddTqKec4Mk5vj8FCFuKOI="
+)
+
+// TestTwilioSignature verifies twilioSignature against the Twilio example vector.
+func TestTwilioSignature(t *testing.T) {
+	got := twilioSignature(twilioVectorAuthToken, twilioVectorURL, twilioVectorForm)
+	if got != twilioVectorSignature {
+		t.Errorf("twilioSignature() = %q, want %q", got, twilioVectorSignature)
+	}
+}
+
+// TestValidateTwilioSignatureModes exercises the strict/log/off toggle
+// against a request signed with the vector above.
+func TestValidateTwilioSignatureModes(t *testing.T) {
+	authToken := twilioVectorAuthToken
+	form := twilioVectorForm
+	validSignature := twilioVectorSignature
+
+	newRequest := func(signature string) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "https://mycompany.com/myapp.php?foo=1&bar=2", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("X-Twilio-Signature", signature)
+		req.Header.Set("X-Forwarded-Proto", "https")
+		return req
+	}
+
+	called := func(mode signatureValidationMode, signature string) bool {
+		nextCalled := false
+		handler := validateTwilioSignature(authToken, mode, func(w http.ResponseWriter, r *http.Request) {
+			nextCalled = true
+		})
+		rec := httptest.NewRecorder()
+		handler(rec, newRequest(signature))
+		return nextCalled
+	}
+
+	if !called(signatureValidationStrict, validSignature) {
+		t.Error("strict mode: valid signature should reach the handler")
+	}
+	if called(signatureValidationStrict, "bogus") {
+		t.Error("strict mode: invalid signature should not reach the handler")
+	}
+	if !called(signatureValidationLog, "bogus") {
+		t.Error("log mode: invalid signature should still reach the handler")
+	}
+	if !called(signatureValidationOff, "bogus") {
+		t.Error("off mode: signature should not be checked at all")
+	}
+}
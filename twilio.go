@@ -0,0 +1,166 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// twilioAPIBase is the root of the Twilio REST API this client talks to.
+const twilioAPIBase = "https://api.twilio.com/2010-04-01"
+
+// twilioMaxRetries bounds the exponential backoff retry loop for rate-limited requests.
+const twilioMaxRetries = 4
+
+// twilioClient wraps the subset of the Twilio REST API used to send
+// messages and (re)configure a phone number's SMS webhook.
+type twilioClient struct {
+	sid        string
+	token      string
+	httpClient *http.Client
+}
+
+// newTwilioClient returns a twilioClient authenticating as accountSID/authToken.
+func newTwilioClient(accountSID, authToken string) *twilioClient {
+	return &twilioClient{
+		sid:        accountSID,
+		token:      authToken,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// twilioPhoneNumber is the subset of Twilio's IncomingPhoneNumber resource used here.
+type twilioPhoneNumber struct {
+	SID          string `json:"sid"`
+	PhoneNumber  string `json:"phone_number"`
+	FriendlyName string `json:"friendly_name"`
+}
+
+// doWithRetry performs req, retrying with exponential backoff on 429
+// responses (honoring Retry-After when present) up to twilioMaxRetries times.
+// Requests built with a body (via http.NewRequest from a strings.Reader, as
+// all callers in this file do) populate req.GetBody, which doWithRetry uses
+// to get a fresh, unread copy of the body before each retry — req.Body is
+// fully drained by the first attempt and cannot simply be reused.
+func (c *twilioClient) doWithRetry(req *http.Request) (*http.Response, error) {
+	var resp *http.Response
+	var err error
+
+	for attempt := 0; attempt <= twilioMaxRetries; attempt++ {
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+
+		resp, err = c.httpClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusTooManyRequests {
+			return resp, nil
+		}
+
+		wait := time.Duration(math.Pow(2, float64(attempt))) * time.Second
+		if retryAfter := resp.Header.Get("Retry-After"); retryAfter != "" {
+			if seconds, err := strconv.Atoi(retryAfter); err == nil {
+				wait = time.Duration(seconds) * time.Second
+			}
+		}
+		resp.Body.Close()
+		time.Sleep(wait)
+	}
+
+	return resp, nil
+}
+
+// SendMessage sends an SMS with body to the given recipient.
+func (c *twilioClient) SendMessage(to, body string) error {
+	endpoint := fmt.Sprintf("%s/Accounts/%s/Messages.json", twilioAPIBase, c.sid)
+
+	form := url.Values{}
+	form.Set("To", to)
+	form.Set("Body", body)
+
+	req, err := http.NewRequest(http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(c.sid, c.token)
+
+	resp, err := c.doWithRetry(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("twilio: send message failed with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// GetIncomingPhoneNumbers returns the account's phone numbers matching friendlyName.
+func (c *twilioClient) GetIncomingPhoneNumbers(friendlyName string) ([]twilioPhoneNumber, error) {
+	endpoint := fmt.Sprintf("%s/Accounts/%s/IncomingPhoneNumbers.json?FriendlyName=%s",
+		twilioAPIBase, c.sid, url.QueryEscape(friendlyName))
+
+	req, err := http.NewRequest(http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth(c.sid, c.token)
+
+	resp, err := c.doWithRetry(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("twilio: list phone numbers failed with status %d", resp.StatusCode)
+	}
+
+	var result struct {
+		IncomingPhoneNumbers []twilioPhoneNumber `json:"incoming_phone_numbers"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	return result.IncomingPhoneNumbers, nil
+}
+
+// UpdateIncomingPhoneNumberSMSWebhook points phoneNumberSID's SMS webhook at webhookURL.
+func (c *twilioClient) UpdateIncomingPhoneNumberSMSWebhook(phoneNumberSID, webhookURL string) error {
+	endpoint := fmt.Sprintf("%s/Accounts/%s/IncomingPhoneNumbers/%s.json", twilioAPIBase, c.sid, phoneNumberSID)
+
+	form := url.Values{}
+	form.Set("SmsUrl", webhookURL)
+	form.Set("SmsMethod", "POST")
+
+	req, err := http.NewRequest(http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(c.sid, c.token)
+
+	resp, err := c.doWithRetry(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("twilio: update webhook failed with status %d", resp.StatusCode)
+	}
+	return nil
+}
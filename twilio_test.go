@@ -0,0 +1,57 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// TestDoWithRetryResendsBodyOnRetry verifies that a POST request with a
+// readable body is retried with its body intact after a 429, rather than
+// sending an empty body once the original strings.Reader is drained.
+func TestDoWithRetryResendsBodyOnRetry(t *testing.T) {
+	var attempts int32
+	var gotBodies []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		gotBodies = append(gotBodies, string(body))
+
+		if atomic.AddInt32(&attempts, 1) <= 2 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := &twilioClient{httpClient: server.Client()}
+
+	req, err := http.NewRequest(http.MethodPost, server.URL, strings.NewReader("Body=hello"))
+	if err != nil {
+		t.Fatalf("http.NewRequest() error = %v", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := client.doWithRetry(req)
+	if err != nil {
+		t.Fatalf("doWithRetry() error = %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("doWithRetry() final status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	if len(gotBodies) != 3 {
+		t.Fatalf("server saw %d attempts, want 3", len(gotBodies))
+	}
+	for i, body := range gotBodies {
+		if body != "Body=hello" {
+			t.Errorf("attempt %d body = %q, want %q", i+1, body, "Body=hello")
+		}
+	}
+}
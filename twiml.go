@@ -0,0 +1,17 @@
+package main
+
+import "strings"
+
+// twimlEscaper escapes characters that are meaningful in XML markup so
+// arbitrary text (e.g. a sender-controlled SMS body) can be safely placed
+// inside a TwiML element without being interpreted as markup.
+var twimlEscaper = strings.NewReplacer(
+	"&", "&amp;",
+	"<", "&lt;",
+	">", "&gt;",
+)
+
+// escapeTwiML escapes s for safe inclusion as TwiML element text content.
+func escapeTwiML(s string) string {
+	return twimlEscaper.Replace(s)
+}